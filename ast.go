@@ -0,0 +1,426 @@
+package yarnlock
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Document is an ordered, comment-preserving AST for a v1 lockfile: unlike
+// LockFile's map, it keeps package entries (and their nested blocks such as
+// "dependencies") in file order and keeps every comment attached to the
+// node it preceded. Parse followed by (*Document).Encode reproduces the
+// original bytes for input laid out the way Encode writes it; see
+// (*Document).Encode for what comes back normalised otherwise.
+type Document struct {
+	// HeaderComments are the comment lines before the first entry, e.g. the
+	// "# yarn lockfile v1" banner. Each string is the raw text following
+	// the "#", unmodified.
+	HeaderComments []string
+	// BlankLinesAfterHeader is how many blank lines separated the header
+	// comments from the first entry.
+	BlankLinesAfterHeader int
+	Entries               []*DocEntry
+}
+
+// DocEntry is one top-level "key, key2: ..." block.
+type DocEntry struct {
+	// LeadingBlankLines is how many blank lines preceded this entry (not
+	// counting the very first entry, which is covered by
+	// Document.BlankLinesAfterHeader).
+	LeadingBlankLines int
+	Comments          []string
+	Keys              []string
+	Fields            []*DocField
+}
+
+// DocField is one "key value" or "key:\n  ..." line inside an entry or a
+// nested block (e.g. "dependencies"). Exactly one of Value or Children is
+// set: Value for a leaf field, Children for a nested block. A DocField with
+// an empty Key and nil Children is a trailing, comment-only entry: it holds
+// comments that came right before a dedent or EOF, with no following field
+// at the same indent to attach them to as leading Comments.
+type DocField struct {
+	Comments []string
+	Key      string
+	Value    string
+	Children []*DocField
+}
+
+// Parse reads a v1 lockfile into a comment- and order-preserving Document.
+// Like ParseLockFileData, it never panics: problems are recorded as
+// position-aware ParseErrors with best-effort recovery (the malformed
+// entry or field is skipped) so the rest of the file still parses, and
+// every problem is returned together as an ErrorList. doc is still
+// populated from whatever could be parsed even when err is non-nil.
+func Parse(data []byte) (doc *Document, err error) {
+	errs := &ErrorList{}
+	tokenizer := _Tokenizer{errs: errs}
+	if e := tokenizer.tokenize(string(data)); e != nil {
+		return nil, e
+	}
+	c := &_astCursor{tokens: tokenizer.tokens, errs: errs}
+	doc = &Document{}
+
+	for c.cur().kind == _TokenComment {
+		doc.HeaderComments = append(doc.HeaderComments, c.cur().value.vString)
+		c.advance()
+		if c.cur().kind == _TokenNewLine {
+			c.advance()
+		}
+	}
+	for c.cur().kind == _TokenNewLine {
+		doc.BlankLinesAfterHeader++
+		c.advance()
+	}
+
+	for c.cur().kind != _TokenEOF {
+		blank := 0
+		for c.cur().kind == _TokenNewLine {
+			blank++
+			c.advance()
+		}
+		if c.cur().kind == _TokenEOF {
+			break
+		}
+		if entry := parseDocEntry(c); entry != nil {
+			entry.LeadingBlankLines = blank
+			doc.Entries = append(doc.Entries, entry)
+		}
+	}
+	return doc, errs.Err()
+}
+
+// _astCursor is a simple rewindable index into a token slice: lookahead
+// that turns out not to apply can be undone by restoring a saved position,
+// which is how parseDocFields decides whether a run of comments belongs to
+// the block it's in or to whatever follows it.
+type _astCursor struct {
+	tokens []_Token
+	pos    int
+	errs   *ErrorList
+}
+
+func (c *_astCursor) cur() _Token {
+	return c.tokens[c.pos]
+}
+
+func (c *_astCursor) advance() _Token {
+	tk := c.tokens[c.pos]
+	if c.pos < len(c.tokens)-1 {
+		c.pos++
+	}
+	return tk
+}
+
+func (c *_astCursor) errorf(format string, args ...interface{}) {
+	if c.errs != nil {
+		c.errs.Add("", c.cur().line+1, c.cur().col+1, fmt.Sprintf(format, args...))
+	}
+}
+
+// recoverEntry skips past the rest of the current line and any lines
+// indented deeper than the top level, so a malformed entry doesn't stop
+// the rest of the document from being parsed and its leftover fields don't
+// get misread as the next entry.
+func (c *_astCursor) recoverEntry() {
+	for c.cur().kind != _TokenNewLine && c.cur().kind != _TokenEOF {
+		c.advance()
+	}
+	for c.cur().kind == _TokenNewLine {
+		checkpoint := c.pos
+		c.advance()
+		if c.cur().kind != _TokenIndent {
+			c.pos = checkpoint
+			return
+		}
+		for c.cur().kind != _TokenNewLine && c.cur().kind != _TokenEOF {
+			c.advance()
+		}
+	}
+}
+
+// parseDocEntry parses one top-level entry, or records a ParseError and
+// returns nil if the entry is malformed.
+func parseDocEntry(c *_astCursor) *DocEntry {
+	entry := &DocEntry{}
+	for c.cur().kind == _TokenComment {
+		entry.Comments = append(entry.Comments, c.cur().value.vString)
+		c.advance()
+		if c.cur().kind == _TokenNewLine {
+			c.advance()
+		}
+	}
+	if c.cur().kind != _TokenString {
+		c.errorf("expected entry key")
+		c.recoverEntry()
+		return nil
+	}
+	entry.Keys = append(entry.Keys, c.cur().value.vString)
+	c.advance()
+	for c.cur().kind == _TokenComma {
+		c.advance()
+		if c.cur().kind != _TokenString {
+			c.errorf("expected entry key")
+			c.recoverEntry()
+			return nil
+		}
+		entry.Keys = append(entry.Keys, c.cur().value.vString)
+		c.advance()
+	}
+	if c.cur().kind != _TokenColon {
+		c.errorf("expected ':'")
+		c.recoverEntry()
+		return nil
+	}
+	c.advance()
+	if c.cur().kind != _TokenNewLine {
+		c.errorf("expected newline after ':'")
+		c.recoverEntry()
+		return nil
+	}
+	c.advance()
+	entry.Fields = parseDocFields(c, 1)
+	return entry
+}
+
+// parseDocFields parses a run of fields at the given indent. A malformed
+// field is skipped (its line is discarded) rather than aborting the rest
+// of the block.
+func parseDocFields(c *_astCursor, indent int) []*DocField {
+	var fields []*DocField
+	for {
+		// A comment line at this level is itself indented, so it shows up
+		// as its own Indent(indent) token followed by a Comment; rewind
+		// past that Indent once it turns out to precede a real field
+		// instead of another comment.
+		var comments []string
+		for c.cur().kind == _TokenIndent && c.cur().value.vInt == indent {
+			checkpoint := c.pos
+			c.advance()
+			if c.cur().kind != _TokenComment {
+				c.pos = checkpoint
+				break
+			}
+			comments = append(comments, c.cur().value.vString)
+			c.advance()
+			if c.cur().kind == _TokenNewLine {
+				c.advance()
+			}
+		}
+		if c.cur().kind != _TokenIndent || c.cur().value.vInt != indent {
+			if len(comments) > 0 {
+				// These comments weren't followed by another field at this
+				// indent (a dedent or EOF came next), so there's no field
+				// to attach them to as leading comments. Keep them as a
+				// trailing, comment-only field instead of dropping them.
+				fields = append(fields, &DocField{Comments: comments})
+			}
+			break
+		}
+		c.advance()
+		field := parseDocField(c, indent)
+		if field == nil {
+			for c.cur().kind != _TokenNewLine && c.cur().kind != _TokenEOF {
+				c.advance()
+			}
+			if c.cur().kind != _TokenNewLine {
+				break
+			}
+			c.advance()
+			continue
+		}
+		field.Comments = comments
+		fields = append(fields, field)
+		if field.Children != nil {
+			// The recursive parseDocFields call already consumed every
+			// newline that belongs to the nested block; the token it left
+			// current (the next line's Indent, a blank line's newline, or
+			// EOF) is the next thing *this* level needs to look at (a
+			// sibling field, a blank line, or dedent), not a terminator
+			// for the "key:" line to be eaten here.
+			continue
+		}
+		if c.cur().kind != _TokenNewLine {
+			break
+		}
+		c.advance()
+	}
+	return fields
+}
+
+func parseDocField(c *_astCursor, indent int) *DocField {
+	if c.cur().kind != _TokenString {
+		c.errorf("expected field key")
+		return nil
+	}
+	field := &DocField{Key: c.cur().value.vString}
+	c.advance()
+	wasColon := c.cur().kind == _TokenColon
+	if wasColon {
+		c.advance()
+	}
+	switch {
+	case isValidPropValueToken(c.cur()):
+		field.Value = renderDocValue(c.cur())
+		c.advance()
+	case wasColon && c.cur().kind == _TokenNewLine:
+		c.advance()
+		field.Children = parseDocFields(c, indent+1)
+	default:
+		c.errorf("invalid value for %q", field.Key)
+		return nil
+	}
+	return field
+}
+
+// renderDocValue renders a leaf token exactly as Encode will emit it, so a
+// DocField never needs to re-derive whether its value was originally a
+// quoted string, a bare identifier, a bool or a number.
+func renderDocValue(tok _Token) string {
+	switch tok.kind {
+	case _TokenBoolean:
+		return strconv.FormatBool(tok.value.vBool)
+	case _TokenNumber:
+		return strconv.Itoa(tok.value.vInt)
+	default:
+		return maybeWrap(tok.value.vString)
+	}
+}
+
+// Encode writes d back out. For a Document produced by Parse without
+// errors, this reproduces the original input byte for byte when that input
+// quotes keys and values the way maybeWrap does, puts a single space
+// between a field's key and value, and ends every line (including the
+// last) in "\n". Input laid out any other way comes back in that
+// normalised form rather than byte for byte.
+func (d *Document) Encode(w io.Writer) error {
+	for _, comment := range d.HeaderComments {
+		if _, err := fmt.Fprintf(w, "#%s\n", comment); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < d.BlankLinesAfterHeader; i++ {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	for i, entry := range d.Entries {
+		if i > 0 {
+			for j := 0; j < entry.LeadingBlankLines; j++ {
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+		}
+		if err := entry.encode(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *DocEntry) encode(w io.Writer) error {
+	for _, comment := range e.Comments {
+		if _, err := fmt.Fprintf(w, "#%s\n", comment); err != nil {
+			return err
+		}
+	}
+	keys := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		keys[i] = maybeWrap(k)
+	}
+	if _, err := fmt.Fprintf(w, "%s:\n", strings.Join(keys, ", ")); err != nil {
+		return err
+	}
+	return encodeDocFields(w, e.Fields, 1)
+}
+
+func encodeDocFields(w io.Writer, fields []*DocField, indent int) error {
+	prefix := strings.Repeat("  ", indent)
+	for _, f := range fields {
+		for _, comment := range f.Comments {
+			if _, err := fmt.Fprintf(w, "%s#%s\n", prefix, comment); err != nil {
+				return err
+			}
+		}
+		if f.Key == "" && f.Children == nil {
+			// A trailing, comment-only field produced by parseDocFields for
+			// comments that precede a dedent or EOF rather than a field.
+			continue
+		}
+		if f.Children != nil {
+			if _, err := fmt.Fprintf(w, "%s%s:\n", prefix, maybeWrap(f.Key)); err != nil {
+				return err
+			}
+			if err := encodeDocFields(w, f.Children, indent+1); err != nil {
+				return err
+			}
+		} else {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", prefix, maybeWrap(f.Key), f.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LockFile builds the flat LockFile view of d, the same shape
+// ParseLockFileData returns. This is the convenience projection of a
+// Document: most callers that don't need to preserve formatting can
+// keep using LockFile and RootElement as before.
+func (d *Document) LockFile() LockFile {
+	lf := LockFile{}
+	for _, entry := range d.Entries {
+		for _, key := range entry.Keys {
+			applyDocFields(lf, key, entry.Fields)
+		}
+	}
+	return lf
+}
+
+func applyDocFields(lf LockFile, key string, fields []*DocField) {
+	e := lf[key]
+	for _, f := range fields {
+		switch f.Key {
+		case "name":
+			e.Name = unwrapDocValue(f.Value)
+		case "version":
+			e.Version = unwrapDocValue(f.Value)
+		case "uid":
+			e.UID = unwrapDocValue(f.Value)
+		case "resolved":
+			e.Resolved = unwrapDocValue(f.Value)
+		case "integrity":
+			e.Integrity = unwrapDocValue(f.Value)
+		case "registry":
+			e.Registry = unwrapDocValue(f.Value)
+		case "dependencies":
+			e.Dependencies = docFieldsToMap(f.Children)
+		case "optionalDependencies":
+			e.OptionalDependencies = docFieldsToMap(f.Children)
+		}
+	}
+	lf[key] = e
+}
+
+func docFieldsToMap(children []*DocField) map[string]string {
+	if len(children) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(children))
+	for _, c := range children {
+		m[c.Key] = unwrapDocValue(c.Value)
+	}
+	return m
+}
+
+func unwrapDocValue(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if v, err := strconv.Unquote(s); err == nil {
+			return v
+		}
+	}
+	return s
+}
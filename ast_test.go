@@ -0,0 +1,124 @@
+package yarnlock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDocumentRoundtrip(t *testing.T) {
+	doc, err := Parse([]byte(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := doc.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, y, b.String())
+}
+
+func TestParseDocumentLockFile(t *testing.T) {
+	doc, err := Parse([]byte(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := ParseLockFileData([]byte(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, want, doc.LockFile())
+}
+
+func TestParseDocumentPreservesComments(t *testing.T) {
+	data := `# yarn lockfile v1
+
+# a leading comment
+foo@^1.0.0:
+  # a field comment
+  version "1.0.0"
+`
+	doc, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{" yarn lockfile v1"}, doc.HeaderComments)
+	assert.Equal(t, []string{" a leading comment"}, doc.Entries[0].Comments)
+	assert.Equal(t, []string{" a field comment"}, doc.Entries[0].Fields[0].Comments)
+
+	var b bytes.Buffer
+	if err := doc.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, b.String())
+}
+
+func TestParseDocumentPreservesTrailingComments(t *testing.T) {
+	data := `foo@^1.0.0:
+  version "1.0.0"
+  # trailing note
+bar@^1.0.0:
+  version "2.0.0"
+`
+	doc, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := doc.Entries[0].Fields
+	assert.Equal(t, []string{" trailing note"}, fields[len(fields)-1].Comments)
+
+	var b bytes.Buffer
+	if err := doc.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, b.String())
+}
+
+func TestParseDocumentPreservesTrailingCommentAtEOF(t *testing.T) {
+	data := `foo@^1.0.0:
+  version "1.0.0"
+  # trailing note
+`
+	doc, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := doc.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, b.String())
+}
+
+func TestParseDocumentRecoversFromErrors(t *testing.T) {
+	data := `foo@^1.0.0:
+  version "1.0.0"
+
+bar@^1.0.0
+  version "1.1.0"
+
+baz@^1.0.0:
+  version "1.2.0"
+`
+	doc, err := Parse([]byte(data))
+	if err == nil {
+		t.Fatal("expected an error for the malformed bar@^1.0.0 entry")
+	}
+	if _, ok := err.(ErrorList); !ok {
+		t.Fatalf("expected an ErrorList, got %T", err)
+	}
+
+	var keys []string
+	for _, entry := range doc.Entries {
+		keys = append(keys, entry.Keys...)
+	}
+	assert.Equal(t, []string{"foo@^1.0.0", "baz@^1.0.0"}, keys)
+}
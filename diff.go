@@ -0,0 +1,299 @@
+package yarnlock
+
+import (
+	"reflect"
+	"sort"
+)
+
+// VersionChange describes one entry whose resolved Version differs between
+// two LockFile snapshots.
+type VersionChange struct {
+	Key  string
+	From string
+	To   string
+}
+
+// Changes is the result of Diff: how two LockFile snapshots of the same
+// project differ, entry by entry. Each slice is sorted by key.
+type Changes struct {
+	Added            []string
+	Removed          []string
+	VersionChanged   []VersionChange
+	IntegrityChanged []string
+}
+
+// Diff compares two LockFiles, typically two revisions of the same
+// yarn.lock, and reports which entries were added, removed, or changed.
+// An entry present in both with a different Version is reported in
+// VersionChanged; one with a different Integrity (but the same Version,
+// e.g. a registry re-publishing the same version) is reported separately
+// in IntegrityChanged.
+func Diff(a, b LockFile) Changes {
+	var c Changes
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			c.Added = append(c.Added, k)
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			c.Removed = append(c.Removed, k)
+		}
+	}
+	for k, av := range a {
+		bv, ok := b[k]
+		if !ok {
+			continue
+		}
+		if av.Version != bv.Version {
+			c.VersionChanged = append(c.VersionChanged, VersionChange{Key: k, From: av.Version, To: bv.Version})
+		} else if av.Integrity != bv.Integrity {
+			c.IntegrityChanged = append(c.IntegrityChanged, k)
+		}
+	}
+	sort.Strings(c.Added)
+	sort.Strings(c.Removed)
+	sort.Slice(c.VersionChanged, func(i, j int) bool { return c.VersionChanged[i].Key < c.VersionChanged[j].Key })
+	sort.Strings(c.IntegrityChanged)
+	return c
+}
+
+// Conflict describes one entry that Merge could not reconcile: base, ours
+// and theirs all disagree (or one side changed an entry the other side
+// removed). *Exists reports whether the entry was present at all on that
+// side, since a missing entry and an entry present-but-unchanged both
+// matter to a caller picking a resolution policy.
+type Conflict struct {
+	Key string
+
+	Base       LockFileEntry
+	BaseExists bool
+
+	Ours       LockFileEntry
+	OursExists bool
+
+	Theirs       LockFileEntry
+	TheirsExists bool
+}
+
+// Merge performs a three-way merge of three LockFiles at the entry level:
+// an entry untouched on one side takes the other side's value, an entry
+// changed to the same value on both sides merges cleanly, and an entry
+// changed to genuinely different values (or changed on one side while
+// removed on the other) is reported in the returned []Conflict instead of
+// being guessed at. The returned LockFile omits every conflicting key, so
+// a caller applies its own policy (prefer-newer-semver, prefer-ours, fail
+// the build) and inserts the resolved entries itself before encoding.
+//
+// Merge never fails on its own; the error return exists for symmetry with
+// the rest of this package's I/O-shaped functions and is always nil today.
+func Merge(base, ours, theirs LockFile) (LockFile, []Conflict, error) {
+	keys := map[string]struct{}{}
+	for k := range base {
+		keys[k] = struct{}{}
+	}
+	for k := range ours {
+		keys[k] = struct{}{}
+	}
+	for k := range theirs {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	merged := LockFile{}
+	var conflicts []Conflict
+	for _, k := range sorted {
+		if v, ok, conflict := mergeEntry(k, base, ours, theirs); conflict != nil {
+			conflicts = append(conflicts, *conflict)
+		} else if ok {
+			merged[k] = v
+		}
+	}
+	return merged, conflicts, nil
+}
+
+// mergeEntry resolves a single key of a three-way merge. It returns either
+// the merged entry (ok==true), nothing (the key was removed on both or
+// cleanly on one side), or a Conflict.
+func mergeEntry(key string, base, ours, theirs LockFile) (LockFileEntry, bool, *Conflict) {
+	b, bOK := base[key]
+	o, oOK := ours[key]
+	t, tOK := theirs[key]
+
+	if !bOK {
+		switch {
+		case oOK && !tOK:
+			return o, true, nil
+		case !oOK && tOK:
+			return t, true, nil
+		case oOK && tOK:
+			if reflect.DeepEqual(o, t) {
+				return o, true, nil
+			}
+			return LockFileEntry{}, false, &Conflict{Key: key, Ours: o, OursExists: true, Theirs: t, TheirsExists: true}
+		default:
+			return LockFileEntry{}, false, nil
+		}
+	}
+
+	switch {
+	case !oOK && !tOK:
+		return LockFileEntry{}, false, nil
+	case oOK && !tOK:
+		if reflect.DeepEqual(o, b) {
+			return LockFileEntry{}, false, nil
+		}
+		return LockFileEntry{}, false, &Conflict{Key: key, Base: b, BaseExists: true, Ours: o, OursExists: true}
+	case !oOK && tOK:
+		if reflect.DeepEqual(t, b) {
+			return LockFileEntry{}, false, nil
+		}
+		return LockFileEntry{}, false, &Conflict{Key: key, Base: b, BaseExists: true, Theirs: t, TheirsExists: true}
+	default:
+		oChanged := !reflect.DeepEqual(o, b)
+		tChanged := !reflect.DeepEqual(t, b)
+		switch {
+		case !oChanged && !tChanged:
+			return b, true, nil
+		case !oChanged:
+			return t, true, nil
+		case !tChanged:
+			return o, true, nil
+		case reflect.DeepEqual(o, t):
+			return o, true, nil
+		default:
+			return LockFileEntry{}, false, &Conflict{
+				Key: key,
+				Base: b, BaseExists: true,
+				Ours: o, OursExists: true,
+				Theirs: t, TheirsExists: true,
+			}
+		}
+	}
+}
+
+// MergeDocument applies a merged LockFile (as returned by Merge, after the
+// caller has resolved any Conflicts into it) back onto doc — typically
+// ours' Document — so that entries whose value didn't change keep their
+// original comments, key grouping and position in the file. An entry whose
+// value did change has its fields regenerated from the merged LockFile
+// (losing any comments attached to those specific field lines); an entry
+// no longer present in merged is dropped, and a key present in merged but
+// not in doc is appended as a new entry. Only version, resolved, integrity,
+// dependencies and optionalDependencies are compared and regenerated: an
+// entry whose merged value differs only in another field (name, uid,
+// registry, or a Berry-only field) keeps its original fields unchanged.
+//
+// An entry with multiple composite keys (e.g. "foo@^1.0.0, foo@^1.1.0") is
+// kept only for the keys still present in merged; if those keys ended up
+// with different merged values (which Merge never itself produces, but a
+// caller's conflict resolution could), the first match wins.
+func MergeDocument(doc *Document, merged LockFile) *Document {
+	out := &Document{HeaderComments: doc.HeaderComments, BlankLinesAfterHeader: doc.BlankLinesAfterHeader}
+	seen := make(map[string]bool, len(merged))
+
+	for _, entry := range doc.Entries {
+		var keys []string
+		var value *LockFileEntry
+		for _, k := range entry.Keys {
+			e, ok := merged[k]
+			if !ok {
+				continue
+			}
+			keys = append(keys, k)
+			seen[k] = true
+			if value == nil {
+				value = &e
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		updated := *entry
+		updated.Keys = keys
+		if !fieldsMatchEntry(entry.Fields, *value) {
+			updated.Fields = entryDocFields(*value)
+		}
+		out.Entries = append(out.Entries, &updated)
+	}
+
+	var added []string
+	for k := range merged {
+		if !seen[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(added)
+	for _, k := range added {
+		leading := 0
+		if len(out.Entries) > 0 {
+			leading = 1
+		}
+		out.Entries = append(out.Entries, &DocEntry{
+			LeadingBlankLines: leading,
+			Keys:              []string{k},
+			Fields:            entryDocFields(merged[k]),
+		})
+	}
+	return out
+}
+
+// fieldsMatchEntry reports whether fields, as parsed back via applyDocFields,
+// has the same version, resolved, integrity, dependencies and
+// optionalDependencies as e; other fields are not compared. It's used to tell a merged entry that's
+// actually unchanged from one whose value really did change, so MergeDocument
+// only regenerates (and loses comments on) the latter.
+func fieldsMatchEntry(fields []*DocField, e LockFileEntry) bool {
+	projected := LockFile{}
+	applyDocFields(projected, "k", fields)
+	p := projected["k"]
+	return p.Version == e.Version &&
+		p.Resolved == e.Resolved &&
+		p.Integrity == e.Integrity &&
+		reflect.DeepEqual(p.Dependencies, e.Dependencies) &&
+		reflect.DeepEqual(p.OptionalDependencies, e.OptionalDependencies)
+}
+
+// entryDocFields renders a LockFileEntry's v1 fields in the same order
+// Encode uses for LockFile: version, resolved, integrity, then the
+// dependency blocks.
+func entryDocFields(e LockFileEntry) []*DocField {
+	var fields []*DocField
+	if e.Version != "" {
+		fields = append(fields, &DocField{Key: "version", Value: maybeWrap(e.Version)})
+	}
+	if e.Resolved != "" {
+		fields = append(fields, &DocField{Key: "resolved", Value: maybeWrap(e.Resolved)})
+	}
+	if e.Integrity != "" {
+		fields = append(fields, &DocField{Key: "integrity", Value: maybeWrap(e.Integrity)})
+	}
+	if len(e.Dependencies) > 0 {
+		fields = append(fields, &DocField{Key: "dependencies", Children: mapDocFields(e.Dependencies)})
+	}
+	if len(e.OptionalDependencies) > 0 {
+		fields = append(fields, &DocField{Key: "optionalDependencies", Children: mapDocFields(e.OptionalDependencies)})
+	}
+	return fields
+}
+
+// mapDocFields renders a dependency map as DocFields, ordered the same way
+// encodeMap orders a LockFile.Encode dependency block.
+func mapDocFields(m map[string]string) []*DocField {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return maybeWrap(keys[i]) < maybeWrap(keys[j])
+	})
+	fields := make([]*DocField, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, &DocField{Key: k, Value: maybeWrap(m[k])})
+	}
+	return fields
+}
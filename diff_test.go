@@ -0,0 +1,147 @@
+package yarnlock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParseLockFile(t *testing.T, data string) LockFile {
+	t.Helper()
+	lf, err := ParseLockFileData([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lf
+}
+
+func TestDiff(t *testing.T) {
+	a := mustParseLockFile(t, `foo@^1.0.0:
+  version "1.0.0"
+  integrity sha512-aaaa==
+
+bar@^1.0.0:
+  version "1.0.0"
+  integrity sha512-bbbb==
+`)
+	b := mustParseLockFile(t, `foo@^1.0.0:
+  version "1.1.0"
+  integrity sha512-cccc==
+
+baz@^1.0.0:
+  version "1.0.0"
+  integrity sha512-dddd==
+`)
+
+	changes := Diff(a, b)
+	assert.Equal(t, []string{"baz@^1.0.0"}, changes.Added)
+	assert.Equal(t, []string{"bar@^1.0.0"}, changes.Removed)
+	assert.Equal(t, []VersionChange{{Key: "foo@^1.0.0", From: "1.0.0", To: "1.1.0"}}, changes.VersionChanged)
+	assert.Empty(t, changes.IntegrityChanged)
+}
+
+func TestMergeCleanCases(t *testing.T) {
+	base := mustParseLockFile(t, `foo@^1.0.0:
+  version "1.0.0"
+
+bar@^1.0.0:
+  version "1.0.0"
+
+baz@^1.0.0:
+  version "1.0.0"
+`)
+	ours := mustParseLockFile(t, `foo@^1.0.0:
+  version "1.0.0"
+
+bar@^1.0.0:
+  version "2.0.0"
+
+qux@^1.0.0:
+  version "1.0.0"
+`)
+	theirs := mustParseLockFile(t, `foo@^1.0.0:
+  version "1.0.0"
+
+bar@^1.0.0:
+  version "2.0.0"
+`)
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, conflicts)
+	assert.Equal(t, "1.0.0", merged["foo@^1.0.0"].Version)
+	assert.Equal(t, "2.0.0", merged["bar@^1.0.0"].Version)
+	assert.Equal(t, "1.0.0", merged["qux@^1.0.0"].Version)
+	_, hasBaz := merged["baz@^1.0.0"]
+	assert.False(t, hasBaz, "baz removed by both ours and theirs should not survive the merge")
+}
+
+func TestMergeReportsConflict(t *testing.T) {
+	base := mustParseLockFile(t, `foo@^1.0.0:
+  version "1.0.0"
+`)
+	ours := mustParseLockFile(t, `foo@^1.0.0:
+  version "1.1.0"
+`)
+	theirs := mustParseLockFile(t, `foo@^1.0.0:
+  version "1.2.0"
+`)
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := merged["foo@^1.0.0"]; ok {
+		t.Fatal("conflicting key should be omitted from the merged LockFile")
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(conflicts))
+	}
+	c := conflicts[0]
+	assert.Equal(t, "foo@^1.0.0", c.Key)
+	assert.Equal(t, "1.0.0", c.Base.Version)
+	assert.Equal(t, "1.1.0", c.Ours.Version)
+	assert.Equal(t, "1.2.0", c.Theirs.Version)
+}
+
+func TestMergeDocumentPreservesFormatting(t *testing.T) {
+	data := `# yarn lockfile v1
+
+# pinned for a security fix, see SECURITY.md
+foo@^1.0.0:
+  version "1.0.0"
+
+bar@^1.0.0:
+  version "1.0.0"
+`
+	doc, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := LockFile{
+		"foo@^1.0.0": {Version: "1.0.0"},
+		"baz@^1.0.0": {Version: "1.0.0"},
+	}
+
+	out := MergeDocument(doc, merged)
+
+	var b bytes.Buffer
+	if err := out.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# yarn lockfile v1
+
+# pinned for a security fix, see SECURITY.md
+foo@^1.0.0:
+  version "1.0.0"
+
+baz@^1.0.0:
+  version "1.0.0"
+`
+	assert.Equal(t, want, b.String())
+}
@@ -0,0 +1,93 @@
+package yarnlock
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// _wrapStopChars mirrors the characters that terminate an unquoted string
+// token in the tokenizer (see _strPattern and bareTokenWidth).
+var _wrapStopChars = regexp.MustCompile(`[:\s,]`)
+
+// maybeWrap quotes s if it could not be round-tripped as an unquoted
+// identifier token by the tokenizer: a bare "true"/"false" would come back
+// as a boolean rather than a string, a bare integer would come back as a
+// number, a stop character would split the value into several tokens, and
+// anything else starting outside _strPattern (a version such as "1.2.3" or
+// a range such as "^1.2.3") is quoted as well.
+func maybeWrap(s string) string {
+	if s == "true" || s == "false" || !_strPattern.MatchString(s) || _wrapStopChars.MatchString(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// encodeMap renders a nested `name:` block with one `key value` line per
+// entry, indented two spaces deeper than indent. Entries are ordered by
+// their wrapped (quoted-or-not) form, matching what a human editing the
+// file by hand would see.
+func encodeMap(m map[string]string, name string, indent string) []string {
+	lines := []string{indent + name + ":"}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return maybeWrap(keys[i]) < maybeWrap(keys[j])
+	})
+	for _, k := range keys {
+		lines = append(lines, indent+"  "+maybeWrap(k)+" "+maybeWrap(m[k]))
+	}
+	return lines
+}
+
+// Encode writes f back out in the classic yarn lockfile v1 format: the
+// standard warning header, then one block per entry sorted by its combined
+// key, reusing encodeMap for the dependency sub-blocks.
+func (f LockFile) Encode(w io.Writer) error {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if _, err := io.WriteString(w, "# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.\n# yarn lockfile v1\n\n\n"); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		entry := f[key]
+		if _, err := io.WriteString(w, maybeWrap(key)+":\n"); err != nil {
+			return err
+		}
+		var lines []string
+		if entry.Version != "" {
+			lines = append(lines, "  version "+maybeWrap(entry.Version))
+		}
+		if entry.Resolved != "" {
+			lines = append(lines, "  resolved "+maybeWrap(entry.Resolved))
+		}
+		if entry.Integrity != "" {
+			lines = append(lines, "  integrity "+maybeWrap(entry.Integrity))
+		}
+		if len(entry.Dependencies) > 0 {
+			lines = append(lines, encodeMap(entry.Dependencies, "dependencies", "  ")...)
+		}
+		if len(entry.OptionalDependencies) > 0 {
+			lines = append(lines, encodeMap(entry.OptionalDependencies, "optionalDependencies", "  ")...)
+		}
+		for _, line := range lines {
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
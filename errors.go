@@ -0,0 +1,50 @@
+package yarnlock
+
+import "fmt"
+
+// ParseError describes a single problem found while tokenizing or parsing a
+// lockfile, in the same spirit as go/scanner.Error: a file name plus a
+// 1-based line/column and a human-readable message.
+type ParseError struct {
+	File string
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Col, e.Msg)
+}
+
+// ErrorList collects the ParseErrors found during a single parse. Both the
+// tokenizer and the parser append to a shared ErrorList so that a broken
+// lockfile is reported in one pass instead of aborting on the first
+// problem; it implements error so an ErrorList itself can be returned as
+// the err result.
+type ErrorList []*ParseError
+
+// Add records a ParseError at the given position.
+func (l *ErrorList) Add(file string, line, col int, msg string) {
+	*l = append(*l, &ParseError{File: file, Line: line, Col: col, Msg: msg})
+}
+
+// Err returns l as an error, or nil if l is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0].Error(), len(l)-1)
+}
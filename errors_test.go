@@ -0,0 +1,33 @@
+package yarnlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLockFileDataRecoversFromErrors(t *testing.T) {
+	data := `foo@^1.0.0:
+  version "1.0.0"
+
+bar@^1.0.0
+  version "1.1.0"
+
+baz@^1.0.0:
+  version "1.2.0"
+`
+	lf, err := ParseLockFileData([]byte(data))
+	if err == nil {
+		t.Fatal("expected an error for the malformed bar@^1.0.0 entry")
+	}
+	errList, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected an ErrorList, got %T", err)
+	}
+	if len(errList) == 0 {
+		t.Fatal("expected at least one ParseError")
+	}
+
+	assert.Equal(t, "1.0.0", lf["foo@^1.0.0"].Version)
+	assert.Equal(t, "1.2.0", lf["baz@^1.0.0"].Version)
+}
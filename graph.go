@@ -0,0 +1,145 @@
+package yarnlock
+
+import "sort"
+
+// Graph is a resolved view of a LockFile's dependency edges: every
+// "name@range" spec in an entry's Dependencies and OptionalDependencies is
+// looked up back into the LockFile to find the concrete key it resolves
+// to, so callers (SBOM generation, audits, "what needs this") can walk the
+// graph instead of re-deriving that lookup themselves. A spec with no
+// matching entry (an unresolved peer dependency, or a lockfile that only
+// covers part of a tree) is simply omitted as an edge.
+type Graph struct {
+	lf         LockFile
+	edges      map[string][]string
+	dependents map[string][]string
+}
+
+// Graph builds a Graph from f. Each key in f becomes a node, and edges are
+// resolved eagerly so Roots, Dependents, Walk and TransitiveClosure are all
+// simple map lookups.
+func (f LockFile) Graph() *Graph {
+	g := &Graph{
+		lf:         f,
+		edges:      map[string][]string{},
+		dependents: map[string][]string{},
+	}
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		entry := f[k]
+		var deps []string
+		deps = appendResolvedDeps(deps, f, entry.Dependencies)
+		deps = appendResolvedDeps(deps, f, entry.OptionalDependencies)
+		sort.Strings(deps)
+		g.edges[k] = deps
+		for _, dep := range deps {
+			g.dependents[dep] = append(g.dependents[dep], k)
+		}
+	}
+	for k := range g.dependents {
+		sort.Strings(g.dependents[k])
+	}
+	return g
+}
+
+// appendResolvedDeps resolves each "name": "range" pair in m to the
+// f[name+"@"+range] entry and appends its key to deps.
+func appendResolvedDeps(deps []string, f LockFile, m map[string]string) []string {
+	for name, rng := range m {
+		spec := name + "@" + rng
+		if _, ok := f[spec]; ok {
+			deps = append(deps, spec)
+		}
+	}
+	return deps
+}
+
+// Roots returns the entries nothing in the lockfile depends on: the
+// project's own direct dependencies. The result is sorted.
+func (g *Graph) Roots() []string {
+	var roots []string
+	for k := range g.lf {
+		if len(g.dependents[k]) == 0 {
+			roots = append(roots, k)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// Dependents returns the entries that directly depend on key (the reverse
+// of Dependencies/OptionalDependencies), sorted. It returns nil if nothing
+// depends on key. The result is a copy, so mutating it cannot corrupt the
+// Graph's own state.
+func (g *Graph) Dependents(key string) []string {
+	deps := g.dependents[key]
+	if len(deps) == 0 {
+		return nil
+	}
+	return append([]string(nil), deps...)
+}
+
+// Walk performs a depth-first traversal of the dependency graph starting
+// at key, calling fn once for every path from key to each node it reaches;
+// path[0] is always key and path[len(path)-1] is the node fn is being
+// called for. A dependency cycle ends that branch instead of recursing
+// forever: fn is still called once for the node that closes the cycle, but
+// Walk does not descend into its dependencies again. If fn returns an
+// error, the walk stops immediately and that error is returned.
+func (g *Graph) Walk(key string, fn func(path []string) error) error {
+	path := []string{key}
+	if err := fn(path); err != nil {
+		return err
+	}
+	return g.walk(path, map[string]bool{key: true}, fn)
+}
+
+func (g *Graph) walk(path []string, onPath map[string]bool, fn func(path []string) error) error {
+	cur := path[len(path)-1]
+	for _, dep := range g.edges[cur] {
+		childPath := append(append([]string{}, path...), dep)
+		if err := fn(childPath); err != nil {
+			return err
+		}
+		if onPath[dep] {
+			continue
+		}
+		onPath[dep] = true
+		if err := g.walk(childPath, onPath, fn); err != nil {
+			return err
+		}
+		delete(onPath, dep)
+	}
+	return nil
+}
+
+// TransitiveClosure returns every entry reachable from key by following
+// Dependencies/OptionalDependencies edges, not including key itself. Unlike
+// Walk, which enumerates every path (and so can revisit a shared dependency
+// once per path to it), TransitiveClosure visits each node at most once, so
+// it stays linear in the size of the graph even when many entries share the
+// same dependencies. The result is sorted.
+func (g *Graph) TransitiveClosure(key string) []string {
+	seen := map[string]bool{key: true}
+	queue := []string{key}
+	var result []string
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.edges[cur] {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			result = append(result, dep)
+			queue = append(queue, dep)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,94 @@
+package yarnlock
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphRootsAndDependents(t *testing.T) {
+	data := `foo@^1.0.0:
+  version "1.0.0"
+  dependencies:
+    bar "^2.0.0"
+
+bar@^2.0.0:
+  version "2.0.0"
+  dependencies:
+    baz "^3.0.0"
+
+baz@^3.0.0:
+  version "3.0.0"
+`
+	lf, err := ParseLockFileData([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := lf.Graph()
+
+	assert.Equal(t, []string{"foo@^1.0.0"}, g.Roots())
+	assert.Equal(t, []string{"foo@^1.0.0"}, g.Dependents("bar@^2.0.0"))
+	assert.Equal(t, []string{"bar@^2.0.0"}, g.Dependents("baz@^3.0.0"))
+	assert.Equal(t, []string(nil), g.Dependents("foo@^1.0.0"))
+	assert.Equal(t, []string{"bar@^2.0.0", "baz@^3.0.0"}, g.TransitiveClosure("foo@^1.0.0"))
+}
+
+func TestGraphWalkDetectsCycle(t *testing.T) {
+	data := `foo@^1.0.0:
+  version "1.0.0"
+  dependencies:
+    bar "^2.0.0"
+
+bar@^2.0.0:
+  version "2.0.0"
+  dependencies:
+    foo "^1.0.0"
+`
+	lf, err := ParseLockFileData([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := lf.Graph()
+
+	var paths [][]string
+	err = g.Walk("foo@^1.0.0", func(path []string) error {
+		cp := append([]string{}, path...)
+		paths = append(paths, cp)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, [][]string{
+		{"foo@^1.0.0"},
+		{"foo@^1.0.0", "bar@^2.0.0"},
+		{"foo@^1.0.0", "bar@^2.0.0", "foo@^1.0.0"},
+	}, paths)
+}
+
+func TestGraphWalkStopsOnError(t *testing.T) {
+	data := `foo@^1.0.0:
+  version "1.0.0"
+  dependencies:
+    bar "^2.0.0"
+
+bar@^2.0.0:
+  version "2.0.0"
+`
+	lf, err := ParseLockFileData([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := lf.Graph()
+
+	stop := errors.New("stop")
+	var calls int
+	err = g.Walk("foo@^1.0.0", func(path []string) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
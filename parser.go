@@ -76,6 +76,14 @@ type _Tokenizer struct {
 	line        int
 	col         int
 	tokens      []_Token
+	file        string
+	errs        *ErrorList
+}
+
+func (t *_Tokenizer) errorf(format string, args ...interface{}) {
+	if t.errs != nil {
+		t.errs.Add(t.file, t.line+1, t.col+1, fmt.Sprintf(format, args...))
+	}
 }
 
 func (t *_Tokenizer) buildToken(tt _TokenKind, value interface{}) {
@@ -93,9 +101,6 @@ func (t *_Tokenizer) buildToken(tt _TokenKind, value interface{}) {
 		tk.value = _TokenValue{vInt: value.(int), vType: _TokenValueInt}
 	}
 	tk.value.valid = true
-	if tt == _TokenInvalid {
-		panic(1)
-	}
 	t.tokens = append(t.tokens, tk)
 }
 
@@ -104,7 +109,7 @@ func (t *_Tokenizer) tokenize(input string) error {
 		var chop = 0
 		if input[0] == '\n' || input[0] == '\r' {
 			chop++
-			if len(input) > 1 && input[1] == '\n' {
+			if input[0] == '\r' && len(input) > 1 && input[1] == '\n' {
 				chop++
 			}
 			t.line++
@@ -123,15 +128,16 @@ func (t *_Tokenizer) tokenize(input string) error {
 		} else if input[0] == ' ' {
 			if t.lastNewLine {
 				indentSize := 1
-				for i := 1; input[i] == ' '; i++ {
+				for i := 1; i < len(input) && input[i] == ' '; i++ {
 					indentSize++
 				}
 				if indentSize%2 == 1 {
-					return errors.New("Invalid number of spaces")
-				} else {
-					chop = indentSize
-					t.buildToken(_TokenIndent, indentSize/2)
+					t.errorf("invalid number of spaces")
+					// recover by rounding down to the nearest even indent
+					indentSize--
 				}
+				chop = indentSize
+				t.buildToken(_TokenIndent, indentSize/2)
 			} else {
 				chop++
 			}
@@ -150,15 +156,24 @@ func (t *_Tokenizer) tokenize(input string) error {
 			chop = i
 			var s string
 			if e := json.Unmarshal([]byte(val), &s); e != nil {
+				t.errorf("invalid quoted string: %v", e)
 				t.buildToken(_TokenInvalid, nil)
 			} else {
 				t.buildToken(_TokenString, s)
 			}
 		} else if input[0] >= '0' && input[0] <= '9' {
-			val := _numberPattern.FindString(input)
+			// A leading digit isn't necessarily a plain integer: Berry
+			// lockfiles routinely leave multi-part version scalars like
+			// "4.17.21" unquoted, which must come back as one string token
+			// rather than a Number("4") followed by a stray ".17.21".
+			val := input[:bareTokenWidth(input)]
 			chop = len(val)
-			n, _ := strconv.Atoi(val)
-			t.buildToken(_TokenNumber, n)
+			if _numberPattern.FindString(val) == val {
+				n, _ := strconv.Atoi(val)
+				t.buildToken(_TokenNumber, n)
+			} else {
+				t.buildToken(_TokenString, val)
+			}
 		} else if strings.HasPrefix(input, "true") {
 			t.buildToken(_TokenBoolean, true)
 			chop = 4
@@ -171,36 +186,57 @@ func (t *_Tokenizer) tokenize(input string) error {
 		} else if input[0] == ',' {
 			t.buildToken(_TokenComma, nil)
 			chop++
-		} else if _strPattern.MatchString(input) {
-			i := 0
-			for ; i < len(input); i++ {
-				char := input[i]
-				if char == ':' || char == ' ' || char == '\r' || char == '\n' || char == ',' {
-					break
-				}
-			}
-			name := input[:i]
-			chop = i
+		} else if _strPattern.MatchString(input) || _rangePattern.MatchString(input) {
+			name := input[:bareTokenWidth(input)]
+			chop = len(name)
 			t.buildToken(_TokenString, name)
-		} else {
-			t.buildToken(_TokenInvalid, nil)
 		}
 		if chop == 0 {
+			// Recovery: nothing above matched (or matched zero bytes), so
+			// record the problem and skip to the next line instead of
+			// looping forever on the same byte.
+			t.errorf("invalid character %q", input[0])
 			t.buildToken(_TokenInvalid, nil)
+			if nextNewLine := strings.IndexAny(input, "\n\r"); nextNewLine == -1 {
+				chop = len(input)
+			} else if nextNewLine == 0 {
+				chop = 1
+			} else {
+				chop = nextNewLine
+			}
 		}
 		t.col += chop
-		t.lastNewLine = input[0] == '\n' || (input[0] == '\r' && input[1] == '\n')
-		if chop == 0 {
-			panic("chop is zero")
-		}
+		t.lastNewLine = input[0] == '\n' || (input[0] == '\r' && len(input) > 1 && input[1] == '\n')
 		input = input[chop:]
 	}
 	t.buildToken(_TokenEOF, nil)
 	return nil
 }
 
+// bareTokenWidth returns the number of bytes of input that belong to a bare
+// (unquoted) scalar token, i.e. everything up to the next token terminator:
+// ':', ' ', '\r', '\n' or ','. input is assumed non-empty and to not start
+// with a terminator.
+func bareTokenWidth(input string) int {
+	for i := 0; i < len(input); i++ {
+		switch input[i] {
+		case ':', ' ', '\r', '\n', ',':
+			return i
+		}
+	}
+	return len(input)
+}
+
 var _numberPattern = regexp.MustCompile("^\\d+")
-var _strPattern = regexp.MustCompile("^[a-zA-Z\\/.-]")
+
+// _strPattern matches the first character of a bare (unquoted) string token.
+// The leading underscore is there for Berry's "__metadata" key, which is
+// never quoted.
+var _strPattern = regexp.MustCompile("^[a-zA-Z_\\/.-]")
+
+// _rangePattern matches the first character of a bare (unquoted) semver
+// range, e.g. Berry's "^2.0.0" or "~2.0.0" dependency values.
+var _rangePattern = regexp.MustCompile("^[\\^~<>=*]")
 var _versionRegex = regexp.MustCompile("^yarn lockfile v(\\d+)$")
 
 const LockfileVersion = 1
@@ -211,11 +247,19 @@ type _Parser struct {
 	tokens   []_Token
 	tokenPtr int
 	comments []string
+	errs     *ErrorList
+}
+
+func (p *_Parser) errorf(format string, args ...interface{}) {
+	if p.errs != nil {
+		p.errs.Add(p.fileLoc, p.token.line+1, p.token.col+1, fmt.Sprintf(format, args...))
+	}
 }
 
 func (p *_Parser) onComment(token _Token) {
 	if !token.isString() {
-		panic("expected token value to be a string")
+		p.errorf("expected comment token to carry a string value")
+		return
 	}
 	comment := strings.TrimSpace(token.value.vString)
 
@@ -223,7 +267,7 @@ func (p *_Parser) onComment(token _Token) {
 	if len(versionMatch) > 0 {
 		version, _ := strconv.Atoi(versionMatch[1])
 		if version > LockfileVersion {
-			panic(fmt.Sprintf("Can't install from a lockfile of version %d as you're on an old yarn version that only supports versions up to %d. Run \\`$ yarn self-update\\` to upgrade to the latest version.", version, LockfileVersion))
+			p.errorf("lockfile declares version %d, but this package only supports versions up to %d", version, LockfileVersion)
 		}
 	}
 	p.comments = append(p.comments, comment)
@@ -231,7 +275,7 @@ func (p *_Parser) onComment(token _Token) {
 
 func (p *_Parser) next() _Token {
 	if p.tokenPtr >= len(p.tokens) {
-		panic("No more tokens")
+		return p.token
 	}
 	tk := p.tokens[p.tokenPtr]
 	p.tokenPtr++
@@ -244,11 +288,31 @@ func (p *_Parser) next() _Token {
 	}
 }
 
+// unexpected records a ParseError at the current token and recovers by
+// skipping ahead to the next newline, so one bad line doesn't stop the rest
+// of the file from being parsed.
 func (p *_Parser) unexpected(msg string) {
 	if msg == "" {
-		panic("Unexpected token")
-	} else {
-		panic(fmt.Sprintf("%s%d:%d in %s", msg, p.token.line, p.token.col, p.fileLoc))
+		msg = "unexpected token"
+	}
+	p.errorf(msg)
+	for p.token.kind != _TokenNewLine && p.token.kind != _TokenEOF {
+		p.next()
+	}
+}
+
+// skipNested discards any lines indented deeper than indent that
+// immediately follow the current newline, so that the body of a malformed
+// entry doesn't get misread as the next entry once parsing resyncs.
+func (p *_Parser) skipNested(indent int) {
+	for p.token.kind == _TokenNewLine {
+		next := p.next()
+		if next.kind != _TokenIndent || next.value.vInt <= indent {
+			return
+		}
+		for p.token.kind != _TokenNewLine && p.token.kind != _TokenEOF {
+			p.next()
+		}
 	}
 }
 
@@ -302,26 +366,35 @@ func (p *_Parser) parse(indent int) interface{} {
 			// property key
 			key := propToken.value
 			if key.IsEmpty() {
-				panic("Expected a key")
+				p.unexpected("expected a key")
+				continue
 			}
 			keys := []_TokenValue{key}
 			p.next()
 			// support multiple keys
+			badKey := false
 			for p.token.kind == _TokenComma {
 				p.next() // skip comma
 
 				keyToken := p.token
 				if keyToken.kind != _TokenString {
-					p.unexpected("Expected string")
+					p.unexpected("expected string")
+					badKey = true
+					break
 				}
 
 				key := keyToken.value
 				if key.IsEmpty() {
-					panic("Expected a key")
+					p.unexpected("expected a key")
+					badKey = true
+					break
 				}
 				keys = append(keys, key)
 				p.next()
 			}
+			if badKey {
+				continue
+			}
 			wasColon := p.token.kind == _TokenColon
 			if wasColon {
 				p.next()
@@ -340,10 +413,12 @@ func (p *_Parser) parse(indent int) interface{} {
 					break
 				}
 			} else {
-				p.unexpected("Invalid value type")
+				p.unexpected("invalid value type")
+				p.skipNested(indent)
 			}
 		} else {
-			p.unexpected(fmt.Sprintf("Unknown token: %v", propToken))
+			p.unexpected(fmt.Sprintf("unknown token: %v", propToken))
+			p.skipNested(indent)
 		}
 	}
 	return obj
@@ -353,7 +428,8 @@ func isValidPropValueToken(token _Token) bool {
 	return token.kind == _TokenBoolean || token.kind == _TokenString || token.kind == _TokenNumber
 }
 
-type LockFile map[string]struct {
+// LockFileEntry is the data held for one resolved package in a LockFile.
+type LockFileEntry struct {
 	Name                 string            `json:"name,omitempty"`
 	Version              string            `json:"version,omitempty"`
 	UID                  string            `json:"uid,omitempty"`
@@ -362,8 +438,18 @@ type LockFile map[string]struct {
 	Registry             string            `json:"registry,omitempty"`
 	Dependencies         map[string]string `json:"dependencies,omitempty"`
 	OptionalDependencies map[string]string `json:"optionalDependencies,omitempty"`
+
+	// The following are only ever populated when the source lockfile is a
+	// Yarn 2+ (Berry) lockfile; see ParseLockFile and LockFile.EncodeV2.
+	LinkType     string `json:"linkType,omitempty"`
+	LanguageName string `json:"languageName,omitempty"`
+	Checksum     string `json:"checksum,omitempty"`
+	Conditions   string `json:"conditions,omitempty"`
+	Resolution   string `json:"resolution,omitempty"`
 }
 
+type LockFile map[string]LockFileEntry
+
 // RootElement returns elements which not be referenced. The result list is sorted.
 func (f LockFile) RootElement() []string {
 	keys := map[string]struct{}{}
@@ -385,41 +471,31 @@ func (f LockFile) RootElement() []string {
 	return rs
 }
 
-type _ParseErr string
-
-func (t _ParseErr) Error() string {
-	return fmt.Sprintf("ParseError: %s", string(t))
-}
-
+// ParseLockFileData parses a v1 lockfile. Tokenizing and parsing never
+// panic: problems are recorded as they're found (with best-effort recovery
+// so later entries still get parsed) and returned together as an
+// ErrorList, so a caller can see every problem in a broken lockfile in one
+// pass instead of just the first. lf is still populated from whatever could
+// be parsed even when err is non-nil.
 func ParseLockFileData(data []byte) (lf LockFile, err error) {
-	defer func() {
-		if e := recover(); e != nil {
-			switch v := e.(type) {
-			case error:
-				err = v
-			case string:
-				err = _ParseErr(v)
-			case fmt.Stringer:
-				err = _ParseErr(v.String())
-			default:
-				err = _ParseErr("Unknown err")
-			}
-		}
-	}()
-	tokenizer := _Tokenizer{}
+	errs := &ErrorList{}
+	tokenizer := _Tokenizer{errs: errs}
 	if e := tokenizer.tokenize(string(data)); e != nil {
 		return nil, e
 	}
 	parser := _Parser{
 		tokens: tokenizer.tokens,
+		errs:   errs,
 	}
 	parser.next()
-	data, e := json.Marshal(parser.parse(0))
+	raw := parser.parse(0)
+
+	jsonData, e := json.Marshal(raw)
 	if e != nil {
 		return nil, errors.Wrap(e, "parse failed")
 	}
-	if e := json.Unmarshal(data, &lf); e != nil {
+	if e := json.Unmarshal(jsonData, &lf); e != nil {
 		return nil, errors.Wrap(e, "parse failed")
 	}
-	return
+	return lf, errs.Err()
 }
@@ -0,0 +1,182 @@
+package yarnlock
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sniffLockfileVersion inspects the leading comment / __metadata block of a
+// lockfile to decide whether it should be parsed as v1 or v2 (Berry). It
+// scans past comment and blank lines until it finds the first other line: a
+// "# yarn lockfile vN" comment with N other than 1 on the way, or a first
+// other line starting with "__metadata:", means v2. Anything else, including
+// a file with only the classic "# yarn lockfile v1" banner, defaults to v1,
+// the long-standing format.
+func sniffLockfileVersion(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			comment := strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+			if m := _versionRegex.FindStringSubmatch(comment); len(m) > 0 && m[1] != "1" {
+				return 2
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "__metadata:") {
+			return 2
+		}
+		break
+	}
+	return 1
+}
+
+// ParseLockFile parses either a v1 or a v2 (Berry) lockfile, sniffing the
+// format first, and returns the same LockFile shape either way. Berry-only
+// fields (LinkType, LanguageName, Checksum, Conditions, Resolution) are left
+// empty when parsing a v1 file.
+func ParseLockFile(data []byte) (LockFile, error) {
+	if sniffLockfileVersion(data) == 2 {
+		return parseLockFileDataV2(data)
+	}
+	return ParseLockFileData(data)
+}
+
+// parseLockFileDataV2 reuses the v1 tokenizer and parser - the grammar only
+// differs in two ways the parser already tolerates: every key is followed by
+// a colon, and a single quoted key can carry several comma-separated specs
+// that all resolve to the same entry (e.g. `"foo@npm:^1.2.3, foo@npm:^1.3.0"`).
+// The __metadata entry is consumed and dropped; every other top-level key is
+// split on ", " and fanned out into one LockFile entry per spec.
+func parseLockFileDataV2(data []byte) (lf LockFile, err error) {
+	errs := &ErrorList{}
+	tokenizer := _Tokenizer{errs: errs}
+	if e := tokenizer.tokenize(string(data)); e != nil {
+		return nil, e
+	}
+	parser := _Parser{tokens: tokenizer.tokens, errs: errs}
+	parser.next()
+
+	rawData, e := json.Marshal(parser.parse(0))
+	if e != nil {
+		return nil, errors.Wrap(e, "parse failed")
+	}
+	var top map[string]json.RawMessage
+	if e := json.Unmarshal(rawData, &top); e != nil {
+		return nil, errors.Wrap(e, "parse failed")
+	}
+
+	lf = LockFile{}
+	for key, value := range top {
+		if key == "__metadata" {
+			continue
+		}
+		var entry struct {
+			Version              string            `json:"version,omitempty"`
+			Resolution           string            `json:"resolution,omitempty"`
+			Dependencies         map[string]string `json:"dependencies,omitempty"`
+			OptionalDependencies map[string]string `json:"optionalDependencies,omitempty"`
+			Checksum             string            `json:"checksum,omitempty"`
+			LanguageName         string            `json:"languageName,omitempty"`
+			LinkType             string            `json:"linkType,omitempty"`
+			Conditions           string            `json:"conditions,omitempty"`
+		}
+		if e := json.Unmarshal(value, &entry); e != nil {
+			return nil, errors.Wrap(e, "parse failed")
+		}
+		for _, spec := range strings.Split(key, ", ") {
+			e := lf[spec]
+			e.Version = entry.Version
+			e.Resolution = entry.Resolution
+			e.Dependencies = entry.Dependencies
+			e.OptionalDependencies = entry.OptionalDependencies
+			e.Checksum = entry.Checksum
+			e.LanguageName = entry.LanguageName
+			e.LinkType = entry.LinkType
+			e.Conditions = entry.Conditions
+			lf[spec] = e
+		}
+	}
+	return lf, errs.Err()
+}
+
+// EncodeV2 writes f out in the Yarn 2+ (Berry) lockfile dialect: a
+// __metadata header followed by one block per entry keyed by its
+// "name@range" spec, using `key: value` pairs rather than v1's bare
+// `key value`. As with v1's Encode, entries that share a resolution are not
+// folded back into a single composite key.
+func (f LockFile) EncodeV2(w io.Writer) error {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if _, err := io.WriteString(w, "# This file is generated by running \"yarn install\" inside your project.\n# Manual changes might be lost - proceed with caution!\n\n__metadata:\n  version: 6\n  cacheKey: 8\n\n"); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		entry := f[key]
+		if _, err := io.WriteString(w, maybeWrap(key)+":\n"); err != nil {
+			return err
+		}
+		var lines []string
+		if entry.Version != "" {
+			lines = append(lines, "  version: "+maybeWrap(entry.Version))
+		}
+		if entry.Resolution != "" {
+			lines = append(lines, "  resolution: "+maybeWrap(entry.Resolution))
+		}
+		if len(entry.Dependencies) > 0 {
+			lines = append(lines, encodeMapV2(entry.Dependencies, "dependencies", "  ")...)
+		}
+		if len(entry.OptionalDependencies) > 0 {
+			lines = append(lines, encodeMapV2(entry.OptionalDependencies, "optionalDependencies", "  ")...)
+		}
+		if entry.Checksum != "" {
+			lines = append(lines, "  checksum: "+maybeWrap(entry.Checksum))
+		}
+		if entry.Conditions != "" {
+			lines = append(lines, "  conditions: "+maybeWrap(entry.Conditions))
+		}
+		if entry.LanguageName != "" {
+			lines = append(lines, "  languageName: "+maybeWrap(entry.LanguageName))
+		}
+		if entry.LinkType != "" {
+			lines = append(lines, "  linkType: "+maybeWrap(entry.LinkType))
+		}
+		for _, line := range lines {
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func encodeMapV2(m map[string]string, name string, indent string) []string {
+	lines := []string{indent + name + ":"}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return maybeWrap(keys[i]) < maybeWrap(keys[j])
+	})
+	for _, k := range keys {
+		lines = append(lines, indent+"  "+maybeWrap(k)+": "+maybeWrap(m[k]))
+	}
+	return lines
+}
@@ -0,0 +1,77 @@
+package yarnlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSniffLockfileVersion(t *testing.T) {
+	assert.Equal(t, 1, sniffLockfileVersion([]byte("# yarn lockfile v1\n\nfoo@^1.0.0:\n  version \"1.0.0\"\n")))
+	assert.Equal(t, 2, sniffLockfileVersion([]byte("# This file is generated by running \"yarn install\"\n\n__metadata:\n  version: 6\n")))
+}
+
+func TestParseLockFileV2(t *testing.T) {
+	data := `# This file is generated by running "yarn install" inside your project.
+# Manual changes might be lost - proceed with caution!
+
+__metadata:
+  version: 6
+  cacheKey: 8
+
+"foo@npm:^1.2.3, foo@npm:^1.3.0":
+  version: "1.2.3"
+  resolution: "foo@npm:1.2.3"
+  checksum: deadbeef
+  languageName: node
+  linkType: hard
+`
+	lf, err := ParseLockFile([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []string{"foo@npm:^1.2.3", "foo@npm:^1.3.0"} {
+		entry, ok := lf[spec]
+		if !ok {
+			t.Fatalf("missing entry for %s", spec)
+		}
+		assert.Equal(t, "1.2.3", entry.Version)
+		assert.Equal(t, "foo@npm:1.2.3", entry.Resolution)
+		assert.Equal(t, "deadbeef", entry.Checksum)
+		assert.Equal(t, "node", entry.LanguageName)
+		assert.Equal(t, "hard", entry.LinkType)
+	}
+}
+
+// TestParseLockFileV2Unquoted covers the common real-world shape Berry
+// itself writes: version and dependency range scalars left bare rather
+// than quoted, unlike the fully-quoted style above.
+func TestParseLockFileV2Unquoted(t *testing.T) {
+	data := `# This file is generated by running "yarn install" inside your project.
+# Manual changes might be lost - proceed with caution!
+
+__metadata:
+  version: 6
+  cacheKey: 8
+
+"foo@npm:^1.2.3":
+  version: 1.2.3
+  resolution: "foo@npm:1.2.3"
+  dependencies:
+    bar: ^2.0.0
+  checksum: deadbeef
+  languageName: node
+  linkType: hard
+`
+	lf, err := ParseLockFile([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := lf["foo@npm:^1.2.3"]
+	if !ok {
+		t.Fatal("missing entry for foo@npm:^1.2.3")
+	}
+	assert.Equal(t, "1.2.3", entry.Version)
+	assert.Equal(t, "foo@npm:1.2.3", entry.Resolution)
+	assert.Equal(t, map[string]string{"bar": "^2.0.0"}, entry.Dependencies)
+}